@@ -0,0 +1,185 @@
+package bee
+
+import (
+	"time"
+)
+
+// CircuitState describes the current disposition of an address's circuit
+// breaker as seen by GetAddressState().
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (self CircuitState) String() string {
+	switch self {
+	case CircuitOpen:
+		return `open`
+	case CircuitHalfOpen:
+		return `half-open`
+	default:
+		return `closed`
+	}
+}
+
+// circuitBreakerState tracks the outlier-detection bookkeeping for a single
+// address.  Access is guarded by MultiClient.checkLock.
+type circuitBreakerState struct {
+	open                 bool
+	consecutiveFailures  int
+	consecutiveSuccesses int
+	openUntil            time.Time
+	halfOpenProbe        bool
+}
+
+// AddressState is a point-in-time snapshot of an address's circuit breaker,
+// returned by GetAddressState() for monitoring purposes.
+type AddressState struct {
+	Address              string
+	State                CircuitState
+	ConsecutiveFailures  int
+	ConsecutiveSuccesses int
+	OpenUntil            time.Time
+}
+
+func (self *MultiClient) ejectionDuration() time.Duration {
+	if self.EjectionDuration > 0 {
+		return self.EjectionDuration
+	}
+
+	return DEFAULT_MULTICLIENT_EJECTION_DURATION
+}
+
+func (self *MultiClient) successesToClose() int {
+	if self.SuccessesToClose > 0 {
+		return self.SuccessesToClose
+	}
+
+	return DEFAULT_MULTICLIENT_SUCCESSES_TO_CLOSE
+}
+
+// availableAddresses filters candidates down to those whose circuit breaker
+// currently permits a request (closed, or half-open and due for a probe).
+func (self *MultiClient) availableAddresses(candidates []string) []string {
+	if self.FailureThreshold <= 0 {
+		return candidates
+	}
+
+	var available []string
+
+	for _, address := range candidates {
+		if self.circuitAllows(address) {
+			available = append(available, address)
+		}
+	}
+
+	return available
+}
+
+// circuitAllows reports whether address may be used for the next request,
+// transitioning an open breaker to half-open (and claiming the single probe
+// slot) once its cooldown has elapsed.
+func (self *MultiClient) circuitAllows(address string) bool {
+	self.checkLock.Lock()
+	defer self.checkLock.Unlock()
+
+	state, ok := self.breakers[address]
+
+	if !ok || state == nil || !state.open {
+		return true
+	}
+
+	if time.Now().Before(state.openUntil) {
+		return false
+	}
+
+	// cooldown has elapsed: allow a single half-open probe through
+	if state.halfOpenProbe {
+		return false
+	}
+
+	state.halfOpenProbe = true
+	return true
+}
+
+// recordRequestResult feeds the outcome of a request against address into
+// its circuit breaker, ejecting it once FailureThreshold consecutive
+// failures are seen and closing it again after SuccessesToClose successes.
+func (self *MultiClient) recordRequestResult(address string, ok bool) {
+	if self.FailureThreshold <= 0 {
+		return
+	}
+
+	self.checkLock.Lock()
+	defer self.checkLock.Unlock()
+
+	if self.breakers == nil {
+		self.breakers = make(map[string]*circuitBreakerState)
+	}
+
+	state, exists := self.breakers[address]
+
+	if !exists {
+		state = new(circuitBreakerState)
+		self.breakers[address] = state
+	}
+
+	state.halfOpenProbe = false
+
+	if ok {
+		state.consecutiveFailures = 0
+
+		if state.open {
+			state.consecutiveSuccesses++
+
+			if state.consecutiveSuccesses >= self.successesToClose() {
+				state.open = false
+				state.consecutiveSuccesses = 0
+			}
+		}
+
+		return
+	}
+
+	state.consecutiveSuccesses = 0
+	state.consecutiveFailures++
+
+	if state.open {
+		// the half-open probe failed: re-open for another full cooldown
+		state.openUntil = time.Now().Add(self.ejectionDuration())
+	} else if state.consecutiveFailures >= self.FailureThreshold {
+		state.open = true
+		state.openUntil = time.Now().Add(self.ejectionDuration())
+	}
+}
+
+// GetAddressState returns a snapshot of address's circuit breaker: whether
+// it is closed, open, or half-open (cooldown elapsed, awaiting a probe),
+// along with its current failure/success counters.
+func (self *MultiClient) GetAddressState(address string) AddressState {
+	self.checkLock.Lock()
+	defer self.checkLock.Unlock()
+
+	out := AddressState{
+		Address: address,
+		State:   CircuitClosed,
+	}
+
+	if state, ok := self.breakers[address]; ok && state != nil {
+		out.ConsecutiveFailures = state.consecutiveFailures
+		out.ConsecutiveSuccesses = state.consecutiveSuccesses
+		out.OpenUntil = state.openUntil
+
+		switch {
+		case state.open && time.Now().Before(state.openUntil):
+			out.State = CircuitOpen
+		case state.open:
+			out.State = CircuitHalfOpen
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,97 @@
+package bee
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerStateTransitions(t *testing.T) {
+	client := NewMultiClient(`addr-1`)
+	client.FailureThreshold = 2
+	client.EjectionDuration = 25 * time.Millisecond
+	client.SuccessesToClose = 1
+
+	if state := client.GetAddressState(`addr-1`); state.State != CircuitClosed {
+		t.Errorf("initial state: should be %q, is %q", CircuitClosed, state.State)
+	}
+
+	// one failure: below threshold, still closed
+	client.recordRequestResult(`addr-1`, false)
+
+	if state := client.GetAddressState(`addr-1`); state.State != CircuitClosed {
+		t.Errorf("state after 1 failure: should be %q, is %q", CircuitClosed, state.State)
+	}
+
+	// second failure crosses the threshold: breaker opens
+	client.recordRequestResult(`addr-1`, false)
+
+	state := client.GetAddressState(`addr-1`)
+
+	if state.State != CircuitOpen {
+		t.Errorf("state after 2 failures: should be %q, is %q", CircuitOpen, state.State)
+	}
+
+	if state.ConsecutiveFailures != 2 {
+		t.Errorf("consecutive failures: should be %d, is %d", 2, state.ConsecutiveFailures)
+	}
+
+	if client.circuitAllows(`addr-1`) {
+		t.Errorf("circuitAllows: should be false while open and within cooldown")
+	}
+
+	// wait out the cooldown: breaker should allow exactly one half-open probe
+	time.Sleep(35 * time.Millisecond)
+
+	if state := client.GetAddressState(`addr-1`); state.State != CircuitHalfOpen {
+		t.Errorf("state after cooldown: should be %q, is %q", CircuitHalfOpen, state.State)
+	}
+
+	if !client.circuitAllows(`addr-1`) {
+		t.Errorf("circuitAllows: should be true for the half-open probe")
+	}
+
+	if client.circuitAllows(`addr-1`) {
+		t.Errorf("circuitAllows: should be false once the half-open probe slot is claimed")
+	}
+
+	// the probe succeeds: breaker closes again
+	client.recordRequestResult(`addr-1`, true)
+
+	if state := client.GetAddressState(`addr-1`); state.State != CircuitClosed {
+		t.Errorf("state after successful probe: should be %q, is %q", CircuitClosed, state.State)
+	}
+
+	if !client.circuitAllows(`addr-1`) {
+		t.Errorf("circuitAllows: should be true once closed")
+	}
+}
+
+func TestCircuitBreakerReopensOnFailedProbe(t *testing.T) {
+	client := NewMultiClient(`addr-1`)
+	client.FailureThreshold = 1
+	client.EjectionDuration = 20 * time.Millisecond
+	client.SuccessesToClose = 1
+
+	client.recordRequestResult(`addr-1`, false)
+
+	if state := client.GetAddressState(`addr-1`); state.State != CircuitOpen {
+		t.Errorf("state after breaching threshold: should be %q, is %q", CircuitOpen, state.State)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if !client.circuitAllows(`addr-1`) {
+		t.Errorf("circuitAllows: should be true for the half-open probe")
+	}
+
+	// the probe fails: breaker re-opens for another full cooldown
+	client.recordRequestResult(`addr-1`, false)
+
+	if state := client.GetAddressState(`addr-1`); state.State != CircuitOpen {
+		t.Errorf("state after failed probe: should be %q, is %q", CircuitOpen, state.State)
+	}
+
+	if client.circuitAllows(`addr-1`) {
+		t.Errorf("circuitAllows: should be false immediately after a failed probe re-opens the breaker")
+	}
+}
@@ -0,0 +1,118 @@
+package bee
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRequestContextCancellationAbortsInFlightRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+
+	defer server.Close()
+
+	client := NewMultiClient(server.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err := client.RequestContext(ctx, `GET`, `/`, nil, nil, nil)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatalf("RequestContext: expected an error after cancellation, got nil")
+	}
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("RequestContext error: should wrap %v, is %v", context.Canceled, err)
+	}
+
+	if elapsed >= 150*time.Millisecond {
+		t.Errorf("RequestContext: should have returned shortly after cancellation, took %s", elapsed)
+	}
+}
+
+func TestRequestTimeoutCancelsSlowAttempt(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+
+	defer server.Close()
+
+	client := NewMultiClient(server.URL)
+	client.RequestTimeout = 20 * time.Millisecond
+
+	start := time.Now()
+	_, err := client.Request(`GET`, `/`, nil, nil, nil)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatalf("Request: expected an error from RequestTimeout, got nil")
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Request error: should wrap %v, is %v", context.DeadlineExceeded, err)
+	}
+
+	if elapsed >= 150*time.Millisecond {
+		t.Errorf("Request: should have returned shortly after RequestTimeout, took %s", elapsed)
+	}
+}
+
+func TestTotalTimeoutBoundsAcrossRetries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	defer server.Close()
+
+	client := NewMultiClient(server.URL)
+	client.TotalTimeout = 30 * time.Millisecond
+	// disable the circuit breaker so that only TotalTimeout can end the retries early
+	client.FailureThreshold = 0
+	client.RetryPolicy = &RetryPolicy{
+		MaxAttempts: 20,
+		BaseDelay:   5 * time.Millisecond,
+		MaxDelay:    5 * time.Millisecond,
+	}
+
+	var lock sync.Mutex
+	attempts := 0
+
+	client.RequestObservers = []RequestObserver{
+		func(attempt int, address string, err error, response *http.Response) {
+			lock.Lock()
+			attempts++
+			lock.Unlock()
+		},
+	}
+
+	_, err := client.Request(`GET`, `/`, nil, nil, nil)
+
+	if err == nil {
+		t.Fatalf("Request: expected an error once TotalTimeout elapses, got nil")
+	}
+
+	if attempts >= 20 {
+		t.Errorf("attempts made: TotalTimeout should have cut retries short of MaxAttempts (20), made %d", attempts)
+	}
+}
@@ -0,0 +1,227 @@
+package bee
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+)
+
+// NDJSONHandler is invoked once per decoded JSON record when decoding an
+// application/x-ndjson or application/stream+json response; pass a value of
+// this type as the `into` argument to receive records as they are parsed.
+type NDJSONHandler func(json.RawMessage) error
+
+// decoderEntry associates a Content-Type glob (matched with path.Match)
+// with the decoder function that should handle it.
+type decoderEntry struct {
+	pattern string
+	fn      ResponseDecoder
+}
+
+// ResponseDecoderRegistry maps Content-Type patterns to ResponseDecoder
+// functions. A MultiClient owns one by default, and any MultiClientRequest
+// may override it with one of its own. RegisterDecoder may safely be called
+// while requests that use this registry are in flight.
+type ResponseDecoderRegistry struct {
+	entries     []decoderEntry
+	entriesLock sync.RWMutex
+}
+
+// NewResponseDecoderRegistry returns a registry pre-populated with decoders
+// for the content types bee-hotel understands out of the box.
+func NewResponseDecoderRegistry() *ResponseDecoderRegistry {
+	registry := new(ResponseDecoderRegistry)
+
+	registry.RegisterDecoder(`application/json`, DecodeJsonResponse)
+	registry.RegisterDecoder(`text/json`, DecodeJsonResponse)
+	registry.RegisterDecoder(`application/xml`, DecodeXmlResponse)
+	registry.RegisterDecoder(`text/xml`, DecodeXmlResponse)
+	registry.RegisterDecoder(`application/x-www-form-urlencoded`, DecodeFormResponse)
+	registry.RegisterDecoder(`application/x-ndjson`, DecodeNdjsonResponse)
+	registry.RegisterDecoder(`application/stream+json`, DecodeNdjsonResponse)
+	registry.RegisterDecoder(`text/plain`, DecodeTextResponse)
+
+	return registry
+}
+
+// defaultResponseDecoders is used by requests that were not built through a
+// MultiClient (e.g. health checks), or whose DecoderRegistry was never set.
+var defaultResponseDecoders = NewResponseDecoderRegistry()
+
+// RegisterDecoder adds fn as the decoder for responses whose Content-Type
+// (ignoring any "; charset=..." suffix) matches contentTypeGlob, which is
+// matched with path.Match (e.g. "application/*"). Later registrations take
+// precedence over earlier ones that match the same content type, so this
+// may also be used to override a built-in decoder.
+func (self *ResponseDecoderRegistry) RegisterDecoder(contentTypeGlob string, fn ResponseDecoder) {
+	self.entriesLock.Lock()
+	defer self.entriesLock.Unlock()
+
+	self.entries = append(self.entries, decoderEntry{pattern: contentTypeGlob, fn: fn})
+}
+
+// Decode routes response to the decoder registered for its Content-Type and
+// invokes it with into. Regardless of Content-Type, if into is an
+// io.ReaderFrom or io.Writer the response body is streamed directly into it
+// without buffering, so large responses can be written straight to disk.
+func (self *ResponseDecoderRegistry) Decode(response *http.Response, into interface{}) error {
+	switch writer := into.(type) {
+	case io.ReaderFrom:
+		_, err := writer.ReadFrom(response.Body)
+		return err
+	case io.Writer:
+		_, err := io.Copy(writer, response.Body)
+		return err
+	}
+
+	contentType := strings.TrimSpace(strings.Split(response.Header.Get(`Content-Type`), `;`)[0])
+
+	self.entriesLock.RLock()
+	entries := self.entries
+	self.entriesLock.RUnlock()
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entry := entries[i]; matchContentType(entry.pattern, contentType) {
+			return entry.fn(response, into)
+		}
+	}
+
+	return DecodeTextResponse(response, into)
+}
+
+func matchContentType(pattern string, contentType string) bool {
+	ok, err := path.Match(pattern, contentType)
+	return err == nil && ok
+}
+
+// unwrapContentEncoding transparently decompresses a gzip- or
+// deflate-encoded response body in place, so decoders never have to know
+// about Content-Encoding.
+func unwrapContentEncoding(response *http.Response) error {
+	switch strings.ToLower(response.Header.Get(`Content-Encoding`)) {
+	case `gzip`:
+		reader, err := gzip.NewReader(response.Body)
+
+		if err != nil {
+			return err
+		}
+
+		response.Body = &decompressedBody{Reader: reader, decoder: reader, wrapped: response.Body}
+	case `deflate`:
+		reader := flate.NewReader(response.Body)
+		response.Body = &decompressedBody{Reader: reader, decoder: reader, wrapped: response.Body}
+	}
+
+	return nil
+}
+
+// decompressedBody wraps a gzip/deflate decompressor so that Close()
+// releases both the decompressor and the original, compressed body it was
+// built from — neither (*gzip.Reader).Close nor flate's reader Close closes
+// the underlying io.Reader.
+type decompressedBody struct {
+	io.Reader
+	decoder io.Closer
+	wrapped io.Closer
+}
+
+func (self *decompressedBody) Close() error {
+	err := self.decoder.Close()
+
+	if wrappedErr := self.wrapped.Close(); err == nil {
+		err = wrappedErr
+	}
+
+	return err
+}
+
+func DecodeJsonResponse(response *http.Response, into interface{}) error {
+	return json.NewDecoder(response.Body).Decode(into)
+}
+
+func DecodeXmlResponse(response *http.Response, into interface{}) error {
+	return xml.NewDecoder(response.Body).Decode(into)
+}
+
+// DecodeFormResponse parses an application/x-www-form-urlencoded body into
+// a *url.Values.
+func DecodeFormResponse(response *http.Response, into interface{}) error {
+	data, err := ioutil.ReadAll(response.Body)
+
+	if err != nil {
+		return err
+	}
+
+	values, err := url.ParseQuery(string(data))
+
+	if err != nil {
+		return err
+	}
+
+	switch out := into.(type) {
+	case *url.Values:
+		*out = values
+		return nil
+	case nil:
+		return nil
+	default:
+		return fmt.Errorf("DecodeFormResponse: unsupported output type %T", into)
+	}
+}
+
+// DecodeTextResponse reads the response body as-is into a *string or
+// *[]byte (io.Writer/io.ReaderFrom outputs are handled earlier, by
+// ResponseDecoderRegistry.Decode).
+func DecodeTextResponse(response *http.Response, into interface{}) error {
+	data, err := ioutil.ReadAll(response.Body)
+
+	if err != nil {
+		return err
+	}
+
+	switch out := into.(type) {
+	case *string:
+		*out = string(data)
+	case *[]byte:
+		*out = data
+	case nil:
+	default:
+		return fmt.Errorf("DecodeTextResponse: unsupported output type %T", into)
+	}
+
+	return nil
+}
+
+// DecodeNdjsonResponse decodes a newline-delimited (or otherwise
+// whitespace-separated) stream of JSON records, invoking the NDJSONHandler
+// passed as into once per record.
+func DecodeNdjsonResponse(response *http.Response, into interface{}) error {
+	handler, ok := into.(NDJSONHandler)
+
+	if !ok {
+		return fmt.Errorf("DecodeNdjsonResponse: into must be an NDJSONHandler, got %T", into)
+	}
+
+	decoder := json.NewDecoder(response.Body)
+
+	for {
+		var record json.RawMessage
+
+		if err := decoder.Decode(&record); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		} else if err := handler(record); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package bee
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDecodeGzipResponseRoundTrip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(`Content-Type`, `application/json`)
+		w.Header().Set(`Content-Encoding`, `gzip`)
+
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+
+		gz.Write([]byte(`{"greeting":"hello"}`))
+	}))
+
+	defer server.Close()
+
+	client := NewMultiClient(server.URL)
+
+	var out struct {
+		Greeting string `json:"greeting"`
+	}
+
+	if _, err := client.Request(`GET`, `/`, nil, &out, nil); err != nil {
+		t.Fatalf("Request: unexpected error %v", err)
+	}
+
+	if out.Greeting != `hello` {
+		t.Errorf("decoded gzip body: should be %q, is %q", `hello`, out.Greeting)
+	}
+}
+
+func TestDecodeNdjsonResponseInvokesHandlerPerRecord(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(`Content-Type`, `application/x-ndjson`)
+		w.Write([]byte("{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n"))
+	}))
+
+	defer server.Close()
+
+	client := NewMultiClient(server.URL)
+
+	var seen []int
+
+	handler := NDJSONHandler(func(record json.RawMessage) error {
+		var decoded struct {
+			N int `json:"n"`
+		}
+
+		if err := json.Unmarshal(record, &decoded); err != nil {
+			return err
+		}
+
+		seen = append(seen, decoded.N)
+		return nil
+	})
+
+	if _, err := client.Request(`GET`, `/`, nil, handler, nil); err != nil {
+		t.Fatalf("Request: unexpected error %v", err)
+	}
+
+	if want := []int{1, 2, 3}; !intsEqual(seen, want) {
+		t.Errorf("NDJSONHandler records: should be %v, is %v", want, seen)
+	}
+}
+
+func TestRegisterDecoderOverridesContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(`Content-Type`, `application/json`)
+		w.Write([]byte(`{"ignored":true}`))
+	}))
+
+	defer server.Close()
+
+	client := NewMultiClient(server.URL)
+
+	client.RegisterDecoder(`application/json`, func(response *http.Response, into interface{}) error {
+		out, ok := into.(*string)
+
+		if !ok {
+			return nil
+		}
+
+		*out = `overridden`
+		return nil
+	})
+
+	var out string
+
+	if _, err := client.Request(`GET`, `/`, nil, &out, nil); err != nil {
+		t.Fatalf("Request: unexpected error %v", err)
+	}
+
+	if out != `overridden` {
+		t.Errorf("RegisterDecoder override: should be %q, is %q", `overridden`, out)
+	}
+}
+
+func intsEqual(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
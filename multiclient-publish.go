@@ -0,0 +1,265 @@
+package bee
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Event describes a single fire-and-forget message submitted via Publish()
+// or PublishBatch(). Key, if set, is used to select a backend address the
+// same way RequestWithKey does.
+type Event struct {
+	Method  string
+	Path    string
+	Payload interface{}
+	Key     string
+}
+
+// BatchEncoding selects how PublishBatch() combines multiple events destined
+// for the same address into a single request body.
+type BatchEncoding int
+
+const (
+	BatchEncodingJSON BatchEncoding = iota
+	BatchEncodingNDJSON
+)
+
+// ensurePublishWorkers lazily starts the publish worker pool the first time
+// Publish(), PublishBatch(), or Flush() is called, so that PublishWorkers
+// and PublishQueue may still be tuned after NewMultiClient().
+func (self *MultiClient) ensurePublishWorkers() {
+	self.publishOnce.Do(func() {
+		workers := self.PublishWorkers
+
+		if workers <= 0 {
+			workers = DEFAULT_MULTICLIENT_PUBLISH_WORKERS
+		}
+
+		queueSize := self.PublishQueue
+
+		if queueSize <= 0 {
+			queueSize = DEFAULT_MULTICLIENT_PUBLISH_QUEUE
+		}
+
+		self.publishQueueCh = make(chan func(), queueSize)
+
+		for i := 0; i < workers; i++ {
+			go self.publishWorker()
+		}
+	})
+}
+
+func (self *MultiClient) publishWorker() {
+	for job := range self.publishQueueCh {
+		job()
+	}
+}
+
+// SetPublishErrorHandler registers fn to be called whenever a published
+// event (via Publish or PublishBatch) ultimately fails to deliver, for
+// dead-letter handling.
+func (self *MultiClient) SetPublishErrorHandler(fn func(Event, error)) {
+	self.publishErrLock.Lock()
+	defer self.publishErrLock.Unlock()
+	self.publishErrFn = fn
+}
+
+func (self *MultiClient) reportPublishError(event Event, err error) {
+	self.publishErrLock.Lock()
+	handler := self.publishErrFn
+	self.publishErrLock.Unlock()
+
+	if handler != nil {
+		handler(event, err)
+	}
+}
+
+// enqueuePublishJob submits job to the publish worker pool, failing immediately if
+// the queue is full rather than blocking the caller.
+func (self *MultiClient) enqueuePublishJob(job func()) error {
+	self.ensurePublishWorkers()
+	self.publishJobs.Add(1)
+
+	select {
+	case self.publishQueueCh <- job:
+		return nil
+	default:
+		self.publishJobs.Done()
+		return fmt.Errorf("Publish queue is full")
+	}
+}
+
+// Publish enqueues a fire-and-forget request: it runs through the same
+// address selection, circuit breaker, and retry logic as Request(), but its
+// response is discarded.
+func (self *MultiClient) Publish(method string, path string, payload interface{}) error {
+	return self.PublishEvent(Event{Method: method, Path: path, Payload: payload})
+}
+
+// PublishEvent behaves like Publish(), but also honors event.Key for
+// address affinity.
+func (self *MultiClient) PublishEvent(event Event) error {
+	return self.enqueuePublishJob(func() {
+		defer self.publishJobs.Done()
+		self.deliverEvent(event)
+	})
+}
+
+func (self *MultiClient) deliverEvent(event Event) {
+	if _, err := self.requestContext(context.Background(), event.Key, event.Method, event.Path, event.Payload, nil, nil); err != nil {
+		self.reportPublishError(event, err)
+	}
+}
+
+// PublishBatch groups events destined for the same backend address (as
+// determined by each event's Key) and method/path, encodes each group's
+// payloads into a single body per BatchEncoding, and delivers one request
+// per group.
+func (self *MultiClient) PublishBatch(events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	type batchGroup struct {
+		address string
+		method  string
+		path    string
+		events  []Event
+	}
+
+	groups := make(map[string]*batchGroup)
+	var order []string
+
+	for _, event := range events {
+		address, err := self.selectAddress(event.Key)
+
+		if err != nil {
+			self.reportPublishError(event, err)
+			continue
+		}
+
+		groupKey := address + ` ` + strings.ToUpper(event.Method) + ` ` + event.Path
+
+		group, exists := groups[groupKey]
+
+		if !exists {
+			group = &batchGroup{address: address, method: strings.ToUpper(event.Method), path: event.Path}
+			groups[groupKey] = group
+			order = append(order, groupKey)
+		}
+
+		group.events = append(group.events, event)
+	}
+
+	var queueErr error
+
+	for _, groupKey := range order {
+		group := groups[groupKey]
+
+		err := self.enqueuePublishJob(func() {
+			defer self.publishJobs.Done()
+			self.deliverBatch(group.address, group.method, group.path, group.events)
+		})
+
+		if err != nil {
+			queueErr = err
+
+			for _, event := range group.events {
+				self.reportPublishError(event, err)
+			}
+		}
+	}
+
+	return queueErr
+}
+
+func (self *MultiClient) deliverBatch(address string, method string, path string, events []Event) {
+	bodyType := BodyJson
+
+	if self.BatchEncoding == BatchEncodingNDJSON {
+		bodyType = BodyRaw
+	}
+
+	payload, err := self.encodeBatch(events)
+
+	if err != nil {
+		for _, event := range events {
+			self.reportPublishError(event, err)
+		}
+
+		return
+	}
+
+	if _, err := self.deliverToAddress(address, method, path, payload, bodyType); err != nil {
+		for _, event := range events {
+			self.reportPublishError(event, err)
+		}
+	}
+}
+
+func (self *MultiClient) encodeBatch(events []Event) (interface{}, error) {
+	if self.BatchEncoding == BatchEncodingNDJSON {
+		var buffer bytes.Buffer
+		encoder := json.NewEncoder(&buffer)
+
+		for _, event := range events {
+			if err := encoder.Encode(event.Payload); err != nil {
+				return nil, err
+			}
+		}
+
+		return buffer.Bytes(), nil
+	}
+
+	payloads := make([]interface{}, len(events))
+
+	for i, event := range events {
+		payloads[i] = event.Payload
+	}
+
+	return payloads, nil
+}
+
+// deliverToAddress performs a request against a specific, already selected
+// address, bypassing address selection but otherwise going through the same
+// circuit breaker bookkeeping and RetryPolicy/RequestObservers/RequestTimeout
+// handling as Request(). Used by PublishBatch, where the address has already
+// been chosen at grouping time.
+func (self *MultiClient) deliverToAddress(address string, method string, path string, payload interface{}, bodyType RequestBodyType) (*http.Response, error) {
+	request, err := NewClientRequest(method, path, payload, bodyType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	request.Client = self.client
+	request.DecoderRegistry = self.ResponseDecoders
+	request.PreRequestHooks = append(append([]PreRequestHook{}, self.PreRequestHooks...), self.LatePreRequestHooks...)
+	request.ImmediatePreRequestHooks = self.ImmediatePreRequestHooks
+
+	return self.performWithRetry(context.Background(), address, ``, request, nil, nil)
+}
+
+// Flush blocks until all currently queued and in-flight published events
+// have been delivered (or failed), or ctx is done.
+func (self *MultiClient) Flush(ctx context.Context) error {
+	self.ensurePublishWorkers()
+
+	done := make(chan struct{})
+
+	go func() {
+		self.publishJobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,177 @@
+package bee
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPublishDeliversEvent(t *testing.T) {
+	var lock sync.Mutex
+	var gotMethod, gotPath string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		gotMethod = r.Method
+		gotPath = r.URL.Path
+		lock.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	defer server.Close()
+
+	client := NewMultiClient(server.URL)
+
+	if err := client.Publish(`POST`, `/events`, map[string]int{`n`: 1}); err != nil {
+		t.Fatalf("Publish: unexpected error %v", err)
+	}
+
+	if err := client.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: unexpected error %v", err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if gotMethod != `POST` {
+		t.Errorf("delivered method: should be %q, is %q", `POST`, gotMethod)
+	}
+
+	if gotPath != `/events` {
+		t.Errorf("delivered path: should be %q, is %q", `/events`, gotPath)
+	}
+}
+
+func TestPublishBatchGroupsAndDelivers(t *testing.T) {
+	var lock sync.Mutex
+	requestCount := 0
+	var lastBody []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make([]byte, r.ContentLength)
+		r.Body.Read(body)
+
+		lock.Lock()
+		requestCount++
+		lastBody = body
+		lock.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	defer server.Close()
+
+	client := NewMultiClient(server.URL)
+
+	events := []Event{
+		{Method: `POST`, Path: `/events`, Payload: map[string]int{`n`: 1}},
+		{Method: `POST`, Path: `/events`, Payload: map[string]int{`n`: 2}},
+	}
+
+	if err := client.PublishBatch(events); err != nil {
+		t.Fatalf("PublishBatch: unexpected error %v", err)
+	}
+
+	if err := client.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: unexpected error %v", err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if requestCount != 1 {
+		t.Errorf("requests made: grouped batch should deliver in %d request, made %d", 1, requestCount)
+	}
+
+	if len(lastBody) == 0 {
+		t.Errorf("batch request body: should not be empty")
+	}
+}
+
+func TestFlushWaitsForInFlightPublishes(t *testing.T) {
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	defer server.Close()
+
+	client := NewMultiClient(server.URL)
+
+	if err := client.Publish(`GET`, `/`, nil); err != nil {
+		t.Fatalf("Publish: unexpected error %v", err)
+	}
+
+	flushed := make(chan error, 1)
+
+	go func() {
+		flushed <- client.Flush(context.Background())
+	}()
+
+	select {
+	case <-flushed:
+		t.Fatalf("Flush: returned before the in-flight publish completed")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+
+	select {
+	case err := <-flushed:
+		if err != nil {
+			t.Errorf("Flush: unexpected error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Flush: did not return after the in-flight publish completed")
+	}
+}
+
+func TestPublishErrorHandlerReceivesDeadLetter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	defer server.Close()
+
+	client := NewMultiClient(server.URL)
+
+	// a retryable status with a single allowed attempt: exhausts immediately
+	// and surfaces as an error, so the dead-letter handler fires
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 1}
+
+	var lock sync.Mutex
+	var failed []Event
+
+	client.SetPublishErrorHandler(func(event Event, err error) {
+		lock.Lock()
+		failed = append(failed, event)
+		lock.Unlock()
+	})
+
+	event := Event{Method: `POST`, Path: `/events`, Payload: map[string]int{`n`: 1}}
+
+	if err := client.PublishEvent(event); err != nil {
+		t.Fatalf("PublishEvent: unexpected error %v", err)
+	}
+
+	if err := client.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: unexpected error %v", err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(failed) != 1 {
+		t.Fatalf("dead-lettered events: should be %d, is %d", 1, len(failed))
+	}
+
+	if failed[0].Path != event.Path {
+		t.Errorf("dead-lettered event path: should be %q, is %q", event.Path, failed[0].Path)
+	}
+}
@@ -2,7 +2,7 @@ package bee
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -25,6 +25,7 @@ type MultiClientRequest struct {
 	Path                     string
 	RequestBody              interface{}
 	ResponseProcessor        ResponseDecoder
+	DecoderRegistry          *ResponseDecoderRegistry
 	Headers                  map[string]interface{}
 	QueryString              map[string]interface{}
 	PreRequestHooks          []PreRequestHook
@@ -54,6 +55,13 @@ func (self *MultiClientRequest) SetBaseUrl(base string) {
 }
 
 func (self *MultiClientRequest) Perform(success interface{}, failure interface{}) (*http.Response, error) {
+	return self.PerformContext(context.Background(), success, failure)
+}
+
+// PerformContext behaves like Perform(), but threads ctx onto the
+// outgoing *http.Request so that cancellation or a deadline set by the
+// caller aborts in-flight I/O.
+func (self *MultiClientRequest) PerformContext(ctx context.Context, success interface{}, failure interface{}) (*http.Response, error) {
 	request := sling.New()
 
 	if self.Client != nil {
@@ -158,8 +166,20 @@ func (self *MultiClientRequest) Perform(success interface{}, failure interface{}
 			}
 		}
 
+		httpReq = httpReq.WithContext(ctx)
+
+		client := self.Client
+
+		if client == nil {
+			client = http.DefaultClient
+		}
+
 		// perform request
-		if response, err := http.DefaultClient.Do(httpReq); err == nil {
+		if response, err := client.Do(httpReq); err == nil {
+			if err := unwrapContentEncoding(response); err != nil {
+				return response, err
+			}
+
 			if response.StatusCode < 400 {
 				return response, self.ResponseProcessor(response, success)
 			} else {
@@ -173,27 +193,29 @@ func (self *MultiClientRequest) Perform(success interface{}, failure interface{}
 	}
 }
 
+// DefaultResponseProcessor dispatches to self.DecoderRegistry (or the
+// package-wide default registry, if none is set) based on the response's
+// Content-Type.
 func (self *MultiClientRequest) DefaultResponseProcessor(response *http.Response, into interface{}) error {
-	switch strings.Split(response.Header.Get(`Content-Type`), `;`)[0] {
-	case `application/json`, `text/json`:
-		return self.DecodeJsonResponse(response, into)
-	case `text/xml`:
-		return self.DecodeXmlResponse(response, into)
-	default:
-		return self.DecodeTextResponse(response, into)
+	registry := self.DecoderRegistry
+
+	if registry == nil {
+		registry = defaultResponseDecoders
 	}
+
+	return registry.Decode(response, into)
 }
 
 func (self *MultiClientRequest) DecodeJsonResponse(response *http.Response, into interface{}) error {
-	return json.NewDecoder(response.Body).Decode(into)
+	return DecodeJsonResponse(response, into)
 }
 
 func (self *MultiClientRequest) DecodeXmlResponse(response *http.Response, into interface{}) error {
-	return xml.NewDecoder(response.Body).Decode(into)
+	return DecodeXmlResponse(response, into)
 }
 
 func (self *MultiClientRequest) DecodeTextResponse(response *http.Response, into interface{}) error {
-	return self.DecodeXmlResponse(response, into)
+	return DecodeTextResponse(response, into)
 }
 
 func (self *MultiClientRequest) QuerySet(key string, value interface{}) {
@@ -0,0 +1,152 @@
+package bee
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const DEFAULT_RETRY_BASE_DELAY = (time.Duration(100) * time.Millisecond)
+const DEFAULT_RETRY_MAX_DELAY = (time.Duration(30) * time.Second)
+const DEFAULT_RETRY_MULTIPLIER = 2.0
+
+// RequestObserver is called once per attempt made by MultiClient.Request()
+// (and its Context/Key variants), whether or not that attempt ultimately
+// succeeded, for metrics and logging purposes.
+type RequestObserver func(attempt int, address string, err error, response *http.Response)
+
+// RetryPolicy governs how MultiClient.Request() spaces out and classifies
+// retries. A nil RetryPolicy preserves the historical behavior: retry
+// immediately on any error, with no backoff.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Multiplier  float64
+	Jitter      float64
+	RetryOn     func(*http.Response, error) bool
+}
+
+// DefaultRetryOn retries on network/transport errors and on 429, 502, 503,
+// and 504 responses. It never retries context cancellation/deadline errors,
+// and never retries any other 4xx status.
+func DefaultRetryOn(response *http.Response, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if err != nil {
+		return true
+	}
+
+	if response == nil {
+		return false
+	}
+
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// nextDelay computes the backoff before the next attempt, applying full
+// jitter around an exponential curve, then clamping to the Retry-After
+// header on 429/503 responses when present.
+func (self *RetryPolicy) nextDelay(attempt int, response *http.Response) time.Duration {
+	base := self.BaseDelay
+
+	if base <= 0 {
+		base = DEFAULT_RETRY_BASE_DELAY
+	}
+
+	maxDelay := self.MaxDelay
+
+	if maxDelay <= 0 {
+		maxDelay = DEFAULT_RETRY_MAX_DELAY
+	}
+
+	multiplier := self.Multiplier
+
+	if multiplier <= 0 {
+		multiplier = DEFAULT_RETRY_MULTIPLIER
+	}
+
+	jitter := self.Jitter
+
+	if jitter < 0 {
+		jitter = 0
+	} else if jitter > 1 {
+		jitter = 1
+	}
+
+	delay := float64(base) * math.Pow(multiplier, float64(attempt))
+
+	if delay > float64(maxDelay) {
+		delay = float64(maxDelay)
+	}
+
+	delay = delay * ((1 - jitter) + rand.Float64()*2*jitter)
+
+	if response != nil {
+		switch response.StatusCode {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			if suggested, ok := retryAfterDelay(response); ok {
+				delay = float64(suggested)
+			}
+		}
+	}
+
+	if delay > float64(maxDelay) {
+		delay = float64(maxDelay)
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+
+	return time.Duration(delay)
+}
+
+// retryAfterDelay parses a Retry-After header in either its seconds or
+// HTTP-date form.
+func retryAfterDelay(response *http.Response) (time.Duration, bool) {
+	value := response.Header.Get(`Retry-After`)
+
+	if value == `` {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if when, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(when); delay > 0 {
+			return delay, true
+		}
+
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// sleepForRetry waits out the backoff for the given attempt, returning
+// early with ctx.Err() if ctx is cancelled or its deadline expires first.
+func sleepForRetry(ctx context.Context, policy *RetryPolicy, attempt int, response *http.Response) error {
+	timer := time.NewTimer(policy.nextDelay(attempt, response))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
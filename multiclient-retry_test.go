@@ -0,0 +1,155 @@
+package bee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryLoopRetriesUntilSuccess(t *testing.T) {
+	var lock sync.Mutex
+	attempts := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		attempts++
+		n := attempts
+		lock.Unlock()
+
+		if n <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	defer server.Close()
+
+	client := NewMultiClient(server.URL)
+	client.RetryPolicy = &RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    5 * time.Millisecond,
+	}
+
+	var observedLock sync.Mutex
+	var observedAttempts []int
+
+	client.RequestObservers = []RequestObserver{
+		func(attempt int, address string, err error, response *http.Response) {
+			observedLock.Lock()
+			observedAttempts = append(observedAttempts, attempt)
+			observedLock.Unlock()
+		},
+	}
+
+	response, err := client.Request(`GET`, `/`, nil, nil, nil)
+
+	if err != nil {
+		t.Fatalf("Request: unexpected error %v", err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Request: status code should be %d, is %d", http.StatusOK, response.StatusCode)
+	}
+
+	if attempts != 3 {
+		t.Errorf("server should have seen %d requests (2 failures + 1 success), saw %d", 3, attempts)
+	}
+
+	if want := []int{0, 1, 2}; !attemptsEqual(observedAttempts, want) {
+		t.Errorf("RequestObservers attempt sequence: should be %v, is %v", want, observedAttempts)
+	}
+}
+
+func TestRetryLoopStopsAtMaxAttempts(t *testing.T) {
+	var lock sync.Mutex
+	attempts := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		attempts++
+		lock.Unlock()
+
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	defer server.Close()
+
+	client := NewMultiClient(server.URL)
+	client.RetryPolicy = &RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    5 * time.Millisecond,
+	}
+
+	_, err := client.Request(`GET`, `/`, nil, nil, nil)
+
+	if err == nil {
+		t.Fatalf("Request: expected an error after exhausting retries, got nil")
+	}
+
+	if attempts != 3 {
+		t.Errorf("attempts made: should be %d (RetryPolicy.MaxAttempts), is %d", 3, attempts)
+	}
+}
+
+func TestRetryLoopRunsPreRequestHookOncePerAttempt(t *testing.T) {
+	var lock sync.Mutex
+	attempts := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		attempts++
+		n := attempts
+		lock.Unlock()
+
+		if n <= 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	defer server.Close()
+
+	client := NewMultiClient(server.URL)
+	client.RetryPolicy = &RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    5 * time.Millisecond,
+	}
+
+	hookCalls := 0
+
+	_, err := client.Request(`GET`, `/`, nil, nil, nil, func(request *MultiClientRequest) error {
+		hookCalls++
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Request: unexpected error %v", err)
+	}
+
+	if hookCalls != attempts {
+		t.Errorf("PreRequestHook calls: should run once per attempt (%d), ran %d times", attempts, hookCalls)
+	}
+}
+
+func attemptsEqual(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
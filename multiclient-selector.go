@@ -0,0 +1,118 @@
+package bee
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+)
+
+// SelectionStrategy controls how MultiClient picks an address for each
+// request, with or without a routing key (see GetAddressForKey).
+type SelectionStrategy int
+
+const (
+	SelectRandom SelectionStrategy = iota
+	SelectRoundRobin
+	SelectRendezvous
+)
+
+// selectAddress picks an address for the given routing key (possibly empty)
+// according to self.SelectionStrategy.
+func (self *MultiClient) selectAddress(key string) (string, error) {
+	return self.GetAddressForKey(key)
+}
+
+// GetAddressForKey selects an address according to self.SelectionStrategy.
+// With SelectRendezvous it deterministically maps key onto one of the
+// currently-eligible addresses using Highest-Random-Weight (rendezvous)
+// hashing, so that repeated calls with the same key land on the same
+// backend even as the address set changes.
+func (self *MultiClient) GetAddressForKey(key string) (string, error) {
+	candidates, err := self.candidateAddresses()
+
+	if err != nil {
+		return ``, err
+	}
+
+	switch self.SelectionStrategy {
+	case SelectRoundRobin:
+		return self.nextRoundRobinAddress(candidates), nil
+	case SelectRendezvous:
+		return self.rendezvousAddress(candidates, key), nil
+	default:
+		return candidates[rand.Intn(len(candidates))], nil
+	}
+}
+
+func (self *MultiClient) nextRoundRobinAddress(candidates []string) string {
+	self.checkLock.Lock()
+	defer self.checkLock.Unlock()
+
+	address := candidates[self.rrCounter%len(candidates)]
+	self.rrCounter++
+
+	return address
+}
+
+// rendezvousAddress implements Highest-Random-Weight hashing: the address
+// with the maximum h = mix64(hash64(key + "|" + address)) wins, ties broken by
+// address string. When AddressWeights is populated, addresses are instead scored by
+// -math.Log(uniform(h))/weight and the minimum score wins.
+func (self *MultiClient) rendezvousAddress(candidates []string, key string) string {
+	weighted := len(self.AddressWeights) > 0
+
+	var best string
+	var bestHash uint64
+	var bestScore float64
+
+	for i, address := range candidates {
+		h := mix64(hash64(key + `|` + address))
+
+		if weighted {
+			weight := self.AddressWeights[address]
+
+			if weight <= 0 {
+				weight = 1
+			}
+
+			uniform := float64(h) / float64(math.MaxUint64)
+
+			if uniform <= 0 {
+				uniform = math.SmallestNonzeroFloat64
+			}
+
+			score := -math.Log(uniform) / weight
+
+			if i == 0 || score < bestScore || (score == bestScore && address < best) {
+				best = address
+				bestScore = score
+			}
+		} else {
+			if i == 0 || h > bestHash || (h == bestHash && address < best) {
+				best = address
+				bestHash = h
+			}
+		}
+	}
+
+	return best
+}
+
+func hash64(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// mix64 is a splitmix64-style finalizer. FNV-1a's avalanche is weak across
+// short shared-prefix inputs (e.g. "key|a" vs "key|b" vs "key|c"), which
+// skews rendezvousAddress toward whichever address sorts first; running the
+// raw hash through this finalizer spreads its bits before comparison.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,92 @@
+package bee
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestRendezvousStableAcrossMembershipChange(t *testing.T) {
+	before := []string{`a`, `b`, `c`, `d`}
+
+	client := NewMultiClient(before...)
+	client.SelectionStrategy = SelectRendezvous
+
+	chosen := client.rendezvousAddress(before, `sticky-key`)
+
+	if chosen == `d` {
+		t.Skip("removed address happened to be the chosen one; rerun")
+	}
+
+	// remove an address other than the one chosen: everyone else's mapping
+	// should be undisturbed
+	after := make([]string, 0, len(before)-1)
+
+	for _, address := range before {
+		if address == `d` {
+			continue
+		}
+
+		after = append(after, address)
+	}
+
+	if got := client.rendezvousAddress(after, `sticky-key`); got != chosen {
+		t.Errorf("rendezvousAddress after removing an unrelated address: should still be %q, is %q", chosen, got)
+	}
+}
+
+func TestRendezvousDistribution(t *testing.T) {
+	candidates := []string{`a`, `b`, `c`}
+	client := NewMultiClient(candidates...)
+	client.SelectionStrategy = SelectRendezvous
+
+	counts := make(map[string]int)
+
+	const samples = 3000
+
+	for i := 0; i < samples; i++ {
+		key := `key-` + strconv.Itoa(i)
+		counts[client.rendezvousAddress(candidates, key)]++
+	}
+
+	for _, address := range candidates {
+		share := float64(counts[address]) / float64(samples)
+
+		if share < 0.2 || share > 0.5 {
+			t.Errorf("rendezvousAddress distribution for %q: share %.2f outside expected range", address, share)
+		}
+	}
+}
+
+func TestRoundRobinCyclesAddresses(t *testing.T) {
+	candidates := []string{`a`, `b`, `c`}
+	client := NewMultiClient(candidates...)
+	client.SelectionStrategy = SelectRoundRobin
+
+	for i := 0; i < len(candidates)*2; i++ {
+		if got, want := client.nextRoundRobinAddress(candidates), candidates[i%len(candidates)]; got != want {
+			t.Errorf("nextRoundRobinAddress call %d: should be %q, is %q", i, want, got)
+		}
+	}
+}
+
+func TestSelectionStrategyAppliesWithoutRoutingKey(t *testing.T) {
+	candidates := []string{`a`, `b`, `c`}
+	client := NewMultiClient(candidates...)
+	client.SelectionStrategy = SelectRoundRobin
+
+	first, err := client.selectAddress(``)
+
+	if err != nil {
+		t.Fatalf("selectAddress: unexpected error %v", err)
+	}
+
+	second, err := client.selectAddress(``)
+
+	if err != nil {
+		t.Fatalf("selectAddress: unexpected error %v", err)
+	}
+
+	if first == second {
+		t.Errorf("selectAddress with no routing key: SelectRoundRobin should still advance between calls, got %q twice", first)
+	}
+}
@@ -1,6 +1,7 @@
 package bee
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"math/rand"
@@ -15,6 +16,11 @@ import (
 const Version = `0.0.5`
 
 const DEFAULT_MULTICLIENT_HEALTHCHECK_TIMEOUT = (time.Duration(10) * time.Second)
+const DEFAULT_MULTICLIENT_FAILURE_THRESHOLD = 5
+const DEFAULT_MULTICLIENT_EJECTION_DURATION = (time.Duration(30) * time.Second)
+const DEFAULT_MULTICLIENT_SUCCESSES_TO_CLOSE = 1
+const DEFAULT_MULTICLIENT_PUBLISH_WORKERS = 4
+const DEFAULT_MULTICLIENT_PUBLISH_QUEUE = 128
 
 type RequestBodyType int
 
@@ -40,7 +46,27 @@ type MultiClient struct {
 	PreRequestHooks          []PreRequestHook
 	LatePreRequestHooks      []PreRequestHook
 	ImmediatePreRequestHooks []ImmediatePreRequestHook
+	FailureThreshold         int
+	EjectionDuration         time.Duration
+	SuccessesToClose         int
+	SelectionStrategy        SelectionStrategy
+	AddressWeights           map[string]float64
+	ResponseDecoders         *ResponseDecoderRegistry
+	RequestTimeout           time.Duration
+	TotalTimeout             time.Duration
+	RetryPolicy              *RetryPolicy
+	RequestObservers         []RequestObserver
+	PublishWorkers           int
+	PublishQueue             int
+	BatchEncoding            BatchEncoding
 	healthyAddresses         []int
+	breakers                 map[string]*circuitBreakerState
+	rrCounter                int
+	publishOnce              sync.Once
+	publishQueueCh           chan func()
+	publishJobs              sync.WaitGroup
+	publishErrFn             func(Event, error)
+	publishErrLock           sync.Mutex
 	checkLock                sync.Mutex
 	active                   bool
 	client                   *http.Client
@@ -58,10 +84,25 @@ func NewMultiClient(addresses ...string) *MultiClient {
 		PreRequestHooks:          make([]PreRequestHook, 0),
 		LatePreRequestHooks:      make([]PreRequestHook, 0),
 		ImmediatePreRequestHooks: make([]ImmediatePreRequestHook, 0),
+		FailureThreshold:         DEFAULT_MULTICLIENT_FAILURE_THRESHOLD,
+		EjectionDuration:         DEFAULT_MULTICLIENT_EJECTION_DURATION,
+		SuccessesToClose:         DEFAULT_MULTICLIENT_SUCCESSES_TO_CLOSE,
+		ResponseDecoders:         NewResponseDecoderRegistry(),
+		PublishWorkers:           DEFAULT_MULTICLIENT_PUBLISH_WORKERS,
+		PublishQueue:             DEFAULT_MULTICLIENT_PUBLISH_QUEUE,
+		breakers:                 make(map[string]*circuitBreakerState),
 		active:                   true,
 	}
 }
 
+// RegisterDecoder adds fn as the decoder this client's requests use for
+// responses matching contentTypeGlob. A MultiClientRequest created directly
+// with NewClientRequest and no DecoderRegistry of its own uses the package
+// default registry instead, which this does not change.
+func (self *MultiClient) RegisterDecoder(contentTypeGlob string, fn ResponseDecoder) {
+	self.ResponseDecoders.RegisterDecoder(contentTypeGlob, fn)
+}
+
 func (self *MultiClient) SetAddresses(addresses ...string) {
 	self.Addresses = addresses
 }
@@ -142,30 +183,54 @@ func (self *MultiClient) GetHealthyAddresses() []string {
 	return addresses
 }
 
-func (self *MultiClient) GetRandomHealthyAddress() (string, error) {
+// candidateAddresses returns the set of addresses currently eligible to
+// receive a request: known-healthy (if health checks are enabled) and not
+// ejected by the circuit breaker.
+func (self *MultiClient) candidateAddresses() ([]string, error) {
+	var candidates []string
+
 	// if we have health checks enabled, only select from known healthy addresses
 	if self.HealthChecks {
 		if len(self.healthyAddresses) == 0 {
-			return ``, fmt.Errorf("No healthy addresses found")
+			return nil, fmt.Errorf("No healthy addresses found")
 		}
 
-		randId := self.healthyAddresses[rand.Intn(len(self.healthyAddresses))]
+		for _, id := range self.healthyAddresses {
+			if id < len(self.Addresses) {
+				candidates = append(candidates, self.Addresses[id])
+			}
+		}
 
-		if randId < len(self.Addresses) {
-			return self.Addresses[randId], nil
-		} else {
-			return ``, fmt.Errorf("No healthy addresses found")
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("No healthy addresses found")
 		}
 	} else {
-		// otherwise, just pick a random address
+		// otherwise, consider all configured addresses
 		if len(self.Addresses) == 0 {
-			return ``, fmt.Errorf("No addresses found")
+			return nil, fmt.Errorf("No addresses found")
 		}
 
-		randAddr := self.Addresses[rand.Intn(len(self.Addresses))]
+		candidates = self.Addresses
+	}
+
+	// exclude addresses whose circuit breaker is currently open
+	available := self.availableAddresses(candidates)
 
-		return randAddr, nil
+	if len(available) == 0 {
+		return nil, fmt.Errorf("No healthy addresses found")
 	}
+
+	return available, nil
+}
+
+func (self *MultiClient) GetRandomHealthyAddress() (string, error) {
+	candidates, err := self.candidateAddresses()
+
+	if err != nil {
+		return ``, err
+	}
+
+	return candidates[rand.Intn(len(candidates))], nil
 }
 
 func (self *MultiClient) checkConnect(minSuccessfulAddresses int) error {
@@ -217,47 +282,158 @@ func (self *MultiClient) CheckAll() error {
 }
 
 func (self *MultiClient) Request(method string, path string, payload interface{}, output interface{}, failure interface{}, preRequestHooks ...PreRequestHook) (*http.Response, error) {
-	var lastErr error
+	return self.requestContext(context.Background(), ``, method, path, payload, output, failure, preRequestHooks...)
+}
 
+// RequestWithKey behaves like Request(), but routes the request using key
+// so that repeated calls with the same key land on the same backend
+// address (see SelectRendezvous).
+func (self *MultiClient) RequestWithKey(key string, method string, path string, payload interface{}, output interface{}, failure interface{}, preRequestHooks ...PreRequestHook) (*http.Response, error) {
+	return self.requestContext(context.Background(), key, method, path, payload, output, failure, preRequestHooks...)
+}
+
+// RequestContext behaves like Request(), but threads ctx through to the
+// underlying HTTP call so that the caller's cancellation or deadline aborts
+// in-flight I/O. It also honors RequestTimeout (per attempt) and
+// TotalTimeout (across all retries), when set.
+func (self *MultiClient) RequestContext(ctx context.Context, method string, path string, payload interface{}, output interface{}, failure interface{}, preRequestHooks ...PreRequestHook) (*http.Response, error) {
+	return self.requestContext(ctx, ``, method, path, payload, output, failure, preRequestHooks...)
+}
+
+func (self *MultiClient) requestContext(ctx context.Context, key string, method string, path string, payload interface{}, output interface{}, failure interface{}, preRequestHooks ...PreRequestHook) (*http.Response, error) {
 	if request, err := NewClientRequest(method, path, payload, self.DefaultBodyType); err == nil {
 		request.Client = self.client
+		request.DecoderRegistry = self.ResponseDecoders
 
-		for i := 0; i < self.RetryLimit; i++ {
-			// get a random healthy address or fail out
-			if address, err := self.GetRandomHealthyAddress(); err == nil {
-				request.SetBaseUrl(address)
+		// combine once up front: folding this into preRequestHooks on every
+		// loop iteration would make it grow (and re-run) with each attempt
+		allPreRequestHooks := append(append([]PreRequestHook{}, self.PreRequestHooks...), preRequestHooks...)
+		allPreRequestHooks = append(allPreRequestHooks, self.LatePreRequestHooks...)
 
-				for k, v := range self.RequestQueryStrings {
-					request.QuerySet(k, v)
-				}
+		request.PreRequestHooks = allPreRequestHooks
+		request.ImmediatePreRequestHooks = self.ImmediatePreRequestHooks
 
-				for k, v := range self.RequestHeaders {
-					request.HeaderSet(k, v)
-				}
+		return self.performWithRetry(ctx, ``, key, request, output, failure)
+	} else {
+		return nil, err
+	}
+}
+
+// performWithRetry drives request through address selection (or, when
+// fixedAddress is set, directly against that address, bypassing selection —
+// used by PublishBatch where the address was already chosen at grouping
+// time), circuit breaker bookkeeping, and the retry/backoff loop shared by
+// Request(), Publish(), and PublishBatch().
+func (self *MultiClient) performWithRetry(ctx context.Context, fixedAddress string, key string, request *MultiClientRequest, output interface{}, failure interface{}) (*http.Response, error) {
+	if self.TotalTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, self.TotalTimeout)
+		defer cancel()
+	}
+
+	var lastErr error
 
-				preRequestHooks = append(self.PreRequestHooks, preRequestHooks...)
-				preRequestHooks = append(preRequestHooks, self.LatePreRequestHooks...)
+	maxAttempts := self.RetryLimit
+
+	if self.RetryPolicy != nil && self.RetryPolicy.MaxAttempts > 0 {
+		maxAttempts = self.RetryPolicy.MaxAttempts
+	}
+
+	for i := 0; i < maxAttempts; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 
-				request.PreRequestHooks = preRequestHooks
-				request.ImmediatePreRequestHooks = self.ImmediatePreRequestHooks
+		address := fixedAddress
+		var err error
 
-				if response, err := request.Perform(output, failure); err == nil {
+		// get a healthy address (random, round-robin, or sticky by key) or fail out
+		if address == `` {
+			address, err = self.selectAddress(key)
+		}
+
+		if err == nil {
+			request.SetBaseUrl(address)
+
+			for k, v := range self.RequestQueryStrings {
+				request.QuerySet(k, v)
+			}
+
+			for k, v := range self.RequestHeaders {
+				request.HeaderSet(k, v)
+			}
+
+			attemptCtx := ctx
+			var cancelAttempt context.CancelFunc
+
+			if self.RequestTimeout > 0 {
+				attemptCtx, cancelAttempt = context.WithTimeout(ctx, self.RequestTimeout)
+			}
+
+			response, performErr := request.PerformContext(attemptCtx, output, failure)
+
+			if cancelAttempt != nil {
+				cancelAttempt()
+			}
+
+			self.recordRequestResult(address, performErr == nil && (response == nil || response.StatusCode < 400))
+
+			for _, observe := range self.RequestObservers {
+				observe(i, address, performErr, response)
+			}
+
+			if self.RetryPolicy == nil {
+				// legacy behavior: return on any non-error response, retry on any error
+				if performErr == nil {
 					return response, nil
-				} else {
-					lastErr = err
 				}
+
+				lastErr = performErr
+
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+
+				continue
+			}
+
+			retryOn := self.RetryPolicy.RetryOn
+
+			if retryOn == nil {
+				retryOn = DefaultRetryOn
+			}
+
+			if performErr == nil && response != nil && response.StatusCode >= 400 {
+				lastErr = fmt.Errorf("request to %s failed with status %d", address, response.StatusCode)
 			} else {
+				lastErr = performErr
+			}
+
+			if performErr == nil && !retryOn(response, nil) {
+				return response, nil
+			} else if performErr != nil && !retryOn(response, performErr) {
+				return nil, performErr
+			}
+
+			if err := ctx.Err(); err != nil {
 				return nil, err
 			}
-		}
 
-		if lastErr != nil {
-			return nil, lastErr
+			if i < maxAttempts-1 {
+				if err := sleepForRetry(ctx, self.RetryPolicy, i, response); err != nil {
+					return nil, err
+				}
+			}
 		} else {
-			return nil, fmt.Errorf("Exceeded retry limit for request")
+			return nil, err
 		}
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
 	} else {
-		return nil, err
+		return nil, fmt.Errorf("Exceeded retry limit for request")
 	}
 }
 